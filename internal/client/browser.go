@@ -0,0 +1,53 @@
+package client
+
+import (
+	"errors"
+	"net"
+	"sync"
+)
+
+// BrowserProfile composes and parses ClientHello/ServerHello traffic that
+// mimics a specific browser's TLS fingerprint (cipher suite ordering,
+// extension ordering, GREASE placement, ...). Cloak ships one per browser it
+// impersonates; RegisterBrowser lets users plug in additional ones (e.g.
+// Safari, iOS WebKit) without touching this package.
+type BrowserProfile interface {
+	// ComposeClientHello builds the obfuscated ClientHello record this
+	// browser would send, embedding sessionKey so the server can recover it,
+	// sni as the advertised ServerName, and ticket as the (possibly empty)
+	// session ticket to present.
+	ComposeClientHello(sessionKey, sni, ticket []byte) []byte
+	// ReadServerResponse reads exactly the bytes of one TLS record from the
+	// server the way this browser's TLS stack would, the same role as
+	// GoQuiet's ReadTillDrain.
+	ReadServerResponse(conn net.Conn, buf []byte) (int, error)
+}
+
+var (
+	browsersM sync.RWMutex
+	browsers  = make(map[string]BrowserProfile)
+)
+
+// RegisterBrowser makes a BrowserProfile available under name for
+// State.BrowserSig to select. It is meant to be called from an init()
+// function, mirroring how database/sql drivers register themselves.
+func RegisterBrowser(name string, p BrowserProfile) {
+	browsersM.Lock()
+	defer browsersM.Unlock()
+	browsers[name] = p
+}
+
+func getBrowser(name string) (BrowserProfile, error) {
+	browsersM.RLock()
+	defer browsersM.RUnlock()
+	p, ok := browsers[name]
+	if !ok {
+		return nil, errors.New("unknown BrowserSig: " + name)
+	}
+	return p, nil
+}
+
+func init() {
+	RegisterBrowser("chrome", chromeProfile{})
+	RegisterBrowser("firefox", firefoxProfile{})
+}
@@ -0,0 +1,50 @@
+package client
+
+import "net"
+
+// firefoxCipherSuites is Firefox's TLS cipher suite list. Firefox omits
+// GREASE entirely and orders ChaCha20 ahead of the AES-256-GCM suites.
+var firefoxCipherSuites = []uint16{
+	0x1301, // TLS_AES_128_GCM_SHA256
+	0x1303, // TLS_CHACHA20_POLY1305_SHA256
+	0x1302, // TLS_AES_256_GCM_SHA384
+	0xc02b, // TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256
+	0xc02f, // TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256
+	0xcca9, // TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256
+	0xcca8, // TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256
+	0xc02c, // TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384
+	0xc030, // TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384
+}
+
+// firefoxExtensions is Firefox's extension ordering, notably putting
+// key_share before supported_versions, the reverse of Chrome.
+var firefoxExtensions = []uint16{
+	0x0000, // server_name
+	0x0017, // extended_master_secret
+	0x0023, // session_ticket
+	0x000d, // signature_algorithms
+	0x0033, // key_share
+	0x002b, // supported_versions
+	0x002d, // psk_key_exchange_modes
+	0x0010, // application_layer_protocol_negotiation
+}
+
+// firefoxProfile implements BrowserProfile with Firefox's ClientHello shape.
+type firefoxProfile struct{}
+
+func (firefoxProfile) ComposeClientHello(sessionKey, sni, ticket []byte) []byte {
+	return composeClientHello(clientHelloParams{
+		ciphers:    firefoxCipherSuites,
+		extensions: firefoxExtensions,
+		// Firefox doesn't GREASE as of this writing.
+		greaseCipher: false,
+		greaseExt:    false,
+		sessionKey:   sessionKey,
+		sni:          sni,
+		ticket:       ticket,
+	})
+}
+
+func (firefoxProfile) ReadServerResponse(conn net.Conn, buf []byte) (int, error) {
+	return readTLSRecord(conn, buf)
+}
@@ -0,0 +1,44 @@
+package client
+
+import (
+	"net"
+	"testing"
+)
+
+// fakeProfile is a minimal BrowserProfile stand-in so RegisterBrowser/
+// getBrowser can be exercised without depending on a real browser's
+// ClientHello shape.
+type fakeProfile struct{}
+
+func (fakeProfile) ComposeClientHello(sessionKey, sni, ticket []byte) []byte { return nil }
+func (fakeProfile) ReadServerResponse(conn net.Conn, buf []byte) (int, error) {
+	return 0, nil
+}
+
+func TestRegisterAndGetBrowser(t *testing.T) {
+	RegisterBrowser("fake-test-browser", fakeProfile{})
+
+	p, err := getBrowser("fake-test-browser")
+	if err != nil {
+		t.Fatalf("getBrowser returned an error for a registered profile: %v", err)
+	}
+	if _, ok := p.(fakeProfile); !ok {
+		t.Fatalf("expected the registered fakeProfile back, got %T", p)
+	}
+}
+
+func TestGetBrowserUnknownName(t *testing.T) {
+	if _, err := getBrowser("not-a-real-browser"); err == nil {
+		t.Fatalf("expected an error for an unregistered BrowserSig")
+	}
+}
+
+// TestDefaultBrowsersRegistered checks the init() registrations chrome and
+// firefox ship with are actually reachable by BrowserSig.
+func TestDefaultBrowsersRegistered(t *testing.T) {
+	for _, name := range []string{"chrome", "firefox"} {
+		if _, err := getBrowser(name); err != nil {
+			t.Fatalf("expected %q to be registered by default, got: %v", name, err)
+		}
+	}
+}
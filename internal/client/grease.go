@@ -0,0 +1,24 @@
+package client
+
+// greaseValues are the reserved GREASE values from RFC 8701 that Chrome (and,
+// increasingly, other browsers) scatters through cipher suites, extensions
+// and supported groups to keep servers from ossifying around a fixed set of
+// values. They all share the byte pattern 0x?A?A.
+var greaseValues = [16]uint16{
+	0x0a0a, 0x1a1a, 0x2a2a, 0x3a3a,
+	0x4a4a, 0x5a5a, 0x6a6a, 0x7a7a,
+	0x8a8a, 0x9a9a, 0xaaaa, 0xbaba,
+	0xcaca, 0xdada, 0xeaea, 0xfafa,
+}
+
+// pickGrease deterministically picks a GREASE value from a byte of the
+// session key, so the same session always advertises the same value instead
+// of looking randomised across retries of the same ClientHello.
+func pickGrease(seed byte) uint16 {
+	return greaseValues[seed%16]
+}
+
+func putUint16(b []byte, v uint16) {
+	b[0] = byte(v >> 8)
+	b[1] = byte(v)
+}
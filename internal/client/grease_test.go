@@ -0,0 +1,27 @@
+package client
+
+import "testing"
+
+// TestPickGreaseDeterministic checks the same seed byte always maps to the
+// same GREASE value, which is what lets a session's ClientHello look
+// identical across retries instead of appearing freshly randomised each time.
+func TestPickGreaseDeterministic(t *testing.T) {
+	for seed := 0; seed < 256; seed++ {
+		want := pickGrease(byte(seed))
+		got := pickGrease(byte(seed))
+		if got != want {
+			t.Fatalf("pickGrease(%d) returned different values across calls: %#x vs %#x", seed, want, got)
+		}
+	}
+}
+
+// TestPickGreaseMatchesRFC8701Pattern checks every value pickGrease can
+// return follows the reserved 0x?A?A byte pattern from RFC 8701.
+func TestPickGreaseMatchesRFC8701Pattern(t *testing.T) {
+	for seed := 0; seed < 256; seed++ {
+		v := pickGrease(byte(seed))
+		if v&0x0f0f != 0x0a0a {
+			t.Fatalf("pickGrease(%d) = %#x, doesn't match the GREASE 0x?A?A pattern", seed, v)
+		}
+	}
+}
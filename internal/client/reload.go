@@ -0,0 +1,111 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// WatchConfig watches the file ParseConfig was last loaded from and hot
+// reloads it on every write, without tearing down any multiplexed session
+// already in flight. Only NumConn, TicketTimeHint, BrowserSig and
+// ProxyMethod may change this way; a diff in any immutable field (UID,
+// PublicKey, ServerName, EncryptionMethod) is refused with a descriptive
+// error instead of being silently applied, since those identify the session
+// cryptographically and changing them requires a restart. WatchConfig blocks
+// until ctx is cancelled.
+func (sta *State) WatchConfig(ctx context.Context) error {
+	if sta.configPath == "" {
+		return errors.New("WatchConfig: State wasn't loaded from a config file")
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to start config watcher: %v", err)
+	}
+	defer watcher.Close()
+
+	if err := watcher.Add(sta.configPath); err != nil {
+		return fmt.Errorf("failed to watch %v: %v", sta.configPath, err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+				continue
+			}
+			if err := sta.reloadConfig(); err != nil {
+				log.Printf("config reload of %v failed, keeping previous config: %v", sta.configPath, err)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			log.Printf("config watcher error: %v", err)
+		}
+	}
+}
+
+// reloadConfig re-parses sta.configPath and, if none of the immutable fields
+// changed, atomically swaps in the new mutable fields.
+func (sta *State) reloadConfig() error {
+	content, format, err := readConfigFile(sta.configPath)
+	if err != nil {
+		return err
+	}
+	preParse, err := unmarshalRawConfig(format, content)
+	if err != nil {
+		return err
+	}
+
+	if _, err := getBrowser(preParse.BrowserSig); err != nil {
+		return err
+	}
+
+	sta.mutableM.Lock()
+	defer sta.mutableM.Unlock()
+
+	uid, err := decodeUID(preParse.UID)
+	if err != nil {
+		return err
+	}
+	if string(uid) != string(sta.UID) {
+		return errors.New("UID changed in config, a restart is required to apply it")
+	}
+	if preParse.PublicKey != "" {
+		pub, err := decodePublicKey(preParse.PublicKey)
+		if err != nil {
+			return err
+		}
+		if fmt.Sprintf("%v", pub) != fmt.Sprintf("%v", sta.staticPub) {
+			return errors.New("PublicKey changed in config, a restart is required to apply it")
+		}
+	}
+	if preParse.EncryptionMethod != "" {
+		method, err := encryptionMethodByte(preParse.EncryptionMethod)
+		if err != nil {
+			return err
+		}
+		if method != sta.EncryptionMethod {
+			return errors.New("EncryptionMethod changed in config, a restart is required to apply it")
+		}
+	}
+	if preParse.ServerName != sta.ServerName {
+		return errors.New("ServerName changed in config, a restart is required to apply it")
+	}
+
+	sta.NumConn = preParse.NumConn
+	sta.TicketTimeHint = preParse.TicketTimeHint
+	sta.BrowserSig = preParse.BrowserSig
+	sta.ProxyMethod = preParse.ProxyMethod
+	return nil
+}
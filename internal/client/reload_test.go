@@ -0,0 +1,123 @@
+package client
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeTestConfig writes a JSON config file with the given fields to a
+// temporary directory and returns its path.
+func writeTestConfig(t *testing.T, uid, pub, serverName, encryptionMethod, browserSig, proxyMethod string, ticketTimeHint, numConn int) string {
+	t.Helper()
+	content := fmt.Sprintf(`{
+		"UID": %q,
+		"PublicKey": %q,
+		"ServerName": %q,
+		"EncryptionMethod": %q,
+		"BrowserSig": %q,
+		"ProxyMethod": %q,
+		"TicketTimeHint": %d,
+		"NumConn": %d
+	}`, uid, pub, serverName, encryptionMethod, browserSig, proxyMethod, ticketTimeHint, numConn)
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("failed to write test config: %v", err)
+	}
+	return path
+}
+
+// baseTestState returns a State as if ParseConfig had already loaded the
+// given immutable fields, ready for reloadConfig to be exercised against.
+func baseTestState(t *testing.T, uid []byte, pub []byte, serverName string, encryptionMethod byte) *State {
+	t.Helper()
+	decodedPub, err := decodePublicKey(base64.StdEncoding.EncodeToString(pub))
+	if err != nil {
+		t.Fatalf("test setup: invalid public key bytes: %v", err)
+	}
+	return &State{
+		UID:              uid,
+		staticPub:        decodedPub,
+		ServerName:       serverName,
+		EncryptionMethod: encryptionMethod,
+	}
+}
+
+func TestReloadConfigAppliesMutableFields(t *testing.T) {
+	uid := []byte("0123456789abcdef")
+	pub := make([]byte, 32)
+	sta := baseTestState(t, uid, pub, "example.com", 0x01)
+	sta.configPath = writeTestConfig(t,
+		base64.StdEncoding.EncodeToString(uid),
+		base64.StdEncoding.EncodeToString(pub),
+		"example.com", "aes", "chrome", "socks5", 30, 4)
+
+	if err := sta.reloadConfig(); err != nil {
+		t.Fatalf("reloadConfig returned an error on an unchanged immutable set: %v", err)
+	}
+
+	if sta.ProxyMethod != "socks5" || sta.TicketTimeHint != 30 || sta.BrowserSig != "chrome" || sta.NumConn != 4 {
+		t.Fatalf("expected the mutable fields to be applied, got %+v", sta)
+	}
+}
+
+func TestReloadConfigRefusesUIDChange(t *testing.T) {
+	uid := []byte("0123456789abcdef")
+	pub := make([]byte, 32)
+	sta := baseTestState(t, uid, pub, "example.com", 0x01)
+	sta.configPath = writeTestConfig(t,
+		base64.StdEncoding.EncodeToString([]byte("fedcba9876543210")),
+		base64.StdEncoding.EncodeToString(pub),
+		"example.com", "aes", "chrome", "socks5", 30, 4)
+
+	if err := sta.reloadConfig(); err == nil {
+		t.Fatalf("expected reloadConfig to refuse a UID change")
+	}
+}
+
+func TestReloadConfigRefusesPublicKeyChange(t *testing.T) {
+	uid := []byte("0123456789abcdef")
+	pub := make([]byte, 32)
+	sta := baseTestState(t, uid, pub, "example.com", 0x01)
+	otherPub := make([]byte, 32)
+	otherPub[0] = 1
+	sta.configPath = writeTestConfig(t,
+		base64.StdEncoding.EncodeToString(uid),
+		base64.StdEncoding.EncodeToString(otherPub),
+		"example.com", "aes", "chrome", "socks5", 30, 4)
+
+	if err := sta.reloadConfig(); err == nil {
+		t.Fatalf("expected reloadConfig to refuse a PublicKey change")
+	}
+}
+
+func TestReloadConfigRefusesEncryptionMethodChange(t *testing.T) {
+	uid := []byte("0123456789abcdef")
+	pub := make([]byte, 32)
+	sta := baseTestState(t, uid, pub, "example.com", 0x01)
+	sta.configPath = writeTestConfig(t,
+		base64.StdEncoding.EncodeToString(uid),
+		base64.StdEncoding.EncodeToString(pub),
+		"example.com", "chacha20-poly1305", "chrome", "socks5", 30, 4)
+
+	if err := sta.reloadConfig(); err == nil {
+		t.Fatalf("expected reloadConfig to refuse an EncryptionMethod change")
+	}
+}
+
+func TestReloadConfigRefusesServerNameChange(t *testing.T) {
+	uid := []byte("0123456789abcdef")
+	pub := make([]byte, 32)
+	sta := baseTestState(t, uid, pub, "example.com", 0x01)
+	sta.configPath = writeTestConfig(t,
+		base64.StdEncoding.EncodeToString(uid),
+		base64.StdEncoding.EncodeToString(pub),
+		"changed.example.com", "aes", "chrome", "socks5", 30, 4)
+
+	if err := sta.reloadConfig(); err == nil {
+		t.Fatalf("expected reloadConfig to refuse a ServerName change")
+	}
+}
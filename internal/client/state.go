@@ -6,22 +6,26 @@ import (
 	"encoding/json"
 	"errors"
 	"io/ioutil"
+	"path/filepath"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+
 	"github.com/cbeuw/Cloak/internal/ecdh"
 )
 
 type rawConfig struct {
-	ServerName       string
-	ProxyMethod      string
-	EncryptionMethod string
-	UID              string
-	PublicKey        string
-	TicketTimeHint   int
-	BrowserSig       string
-	NumConn          int
+	ServerName       string `yaml:"ServerName" toml:"ServerName"`
+	ProxyMethod      string `yaml:"ProxyMethod" toml:"ProxyMethod"`
+	EncryptionMethod string `yaml:"EncryptionMethod" toml:"EncryptionMethod"`
+	UID              string `yaml:"UID" toml:"UID"`
+	PublicKey        string `yaml:"PublicKey" toml:"PublicKey"`
+	TicketTimeHint   int    `yaml:"TicketTimeHint" toml:"TicketTimeHint"`
+	BrowserSig       string `yaml:"BrowserSig" toml:"BrowserSig"`
+	NumConn          int    `yaml:"NumConn" toml:"NumConn"`
 }
 
 // State stores global variables
@@ -38,12 +42,25 @@ type State struct {
 	keyPairsM sync.RWMutex
 	keyPairs  map[int64]*keyPair
 
-	ProxyMethod      string
+	// configPath is the file ParseConfig last loaded from, empty if it was
+	// given an inline Android SSV blob instead. WatchConfig needs it to know
+	// what to re-read on a filesystem event.
+	configPath string
+
 	EncryptionMethod byte
-	TicketTimeHint   int
 	ServerName       string
-	BrowserSig       string
-	NumConn          int
+
+	// mutableM guards the four fields below, the ones WatchConfig's reload
+	// path is allowed to change while the program is running. ParseConfig's
+	// initial load writes them before WatchConfig ever starts, so it needs
+	// no lock; reloadConfig takes mutableM.Lock() to write them, and any
+	// other code that reads them concurrently with a live reload must take
+	// mutableM.RLock() around the read or it can observe a torn update.
+	mutableM       sync.RWMutex
+	ProxyMethod    string
+	TicketTimeHint int
+	BrowserSig     string
+	NumConn        int
 }
 
 func InitState(localHost, localPort, remoteHost, remotePort string, nowFunc func() time.Time) *State {
@@ -90,54 +107,138 @@ func ssvToJson(ssv string) (ret []byte) {
 	return ret
 }
 
-// ParseConfig parses the config (either a path to json or Android config) into a State variable
+// ParseConfig parses the config (a path to a JSON/YAML/TOML file, or the
+// Android SSV blob) into a State variable
 func (sta *State) ParseConfig(conf string) (err error) {
 	var content []byte
+	var format string
 	if strings.Contains(conf, ";") && strings.Contains(conf, "=") {
 		content = ssvToJson(conf)
+		format = "json"
 	} else {
-		content, err = ioutil.ReadFile(conf)
+		content, format, err = readConfigFile(conf)
 		if err != nil {
 			return err
 		}
+		sta.configPath = conf
 	}
-	var preParse rawConfig
-	err = json.Unmarshal(content, &preParse)
+
+	preParse, err := unmarshalRawConfig(format, content)
 	if err != nil {
 		return err
 	}
 
-	switch preParse.EncryptionMethod {
-	case "plain":
-		sta.EncryptionMethod = 0x00
-	case "aes":
-		sta.EncryptionMethod = 0x01
-	case "chacha20-poly1305":
-		sta.EncryptionMethod = 0x02
+	return sta.applyRawConfig(preParse)
+}
+
+// detectConfigFormat picks json/yaml/toml based on the file extension first,
+// falling back to sniffing the content for anyone who names their file
+// something else.
+func detectConfigFormat(path string, content []byte) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return "yaml"
+	case ".toml":
+		return "toml"
+	case ".json":
+		return "json"
+	}
+
+	trimmed := strings.TrimSpace(string(content))
+	if strings.HasPrefix(trimmed, "{") {
+		return "json"
+	}
+	if strings.Contains(trimmed, "=") && !strings.Contains(trimmed, ":") {
+		return "toml"
+	}
+	return "yaml"
+}
+
+func unmarshalRawConfig(format string, content []byte) (preParse rawConfig, err error) {
+	switch format {
+	case "yaml":
+		err = yaml.Unmarshal(content, &preParse)
+	case "toml":
+		err = toml.Unmarshal(content, &preParse)
 	default:
-		return errors.New("Unknown encryption method")
+		err = json.Unmarshal(content, &preParse)
 	}
+	return
+}
 
+// applyRawConfig validates and copies a parsed config into sta. It is used
+// by the initial ParseConfig; WatchConfig's reload path uses
+// reloadConfig instead since it must refuse changes to immutable fields.
+func (sta *State) applyRawConfig(preParse rawConfig) error {
+	encryptionMethod, err := encryptionMethodByte(preParse.EncryptionMethod)
+	if err != nil {
+		return err
+	}
+
+	if _, err := getBrowser(preParse.BrowserSig); err != nil {
+		return err
+	}
+
+	uid, err := decodeUID(preParse.UID)
+	if err != nil {
+		return err
+	}
+
+	pub, err := decodePublicKey(preParse.PublicKey)
+	if err != nil {
+		return err
+	}
+
+	sta.EncryptionMethod = encryptionMethod
 	sta.ProxyMethod = preParse.ProxyMethod
 	sta.ServerName = preParse.ServerName
 	sta.TicketTimeHint = preParse.TicketTimeHint
 	sta.BrowserSig = preParse.BrowserSig
 	sta.NumConn = preParse.NumConn
+	sta.UID = uid
+	sta.staticPub = pub
+	return nil
+}
 
-	uid, err := base64.StdEncoding.DecodeString(preParse.UID)
+func encryptionMethodByte(s string) (byte, error) {
+	switch s {
+	case "plain":
+		return 0x00, nil
+	case "aes":
+		return 0x01, nil
+	case "chacha20-poly1305":
+		return 0x02, nil
+	default:
+		return 0, errors.New("Unknown encryption method")
+	}
+}
+
+func decodeUID(s string) ([]byte, error) {
+	uid, err := base64.StdEncoding.DecodeString(s)
 	if err != nil {
-		return errors.New("Failed to parse UID: " + err.Error())
+		return nil, errors.New("Failed to parse UID: " + err.Error())
 	}
-	sta.UID = uid
+	return uid, nil
+}
 
-	pubBytes, err := base64.StdEncoding.DecodeString(preParse.PublicKey)
+func decodePublicKey(s string) (crypto.PublicKey, error) {
+	pubBytes, err := base64.StdEncoding.DecodeString(s)
 	if err != nil {
-		return errors.New("Failed to parse Public key: " + err.Error())
+		return nil, errors.New("Failed to parse Public key: " + err.Error())
 	}
 	pub, ok := ecdh.Unmarshal(pubBytes)
 	if !ok {
-		return errors.New("Failed to unmarshal Public key")
+		return nil, errors.New("Failed to unmarshal Public key")
 	}
-	sta.staticPub = pub
-	return nil
+	return pub, nil
+}
+
+// readConfigFile reads a config file off disk and determines its format
+// from its extension or, failing that, its content.
+func readConfigFile(path string) (content []byte, format string, err error) {
+	content, err = ioutil.ReadFile(path)
+	if err != nil {
+		return nil, "", err
+	}
+	return content, detectConfigFormat(path, content), nil
 }
@@ -0,0 +1,69 @@
+// Package metrics exposes multiplex.Session stats in the Prometheus text
+// exposition format, so ck-client and ck-server can mount a /metrics
+// handler without either of them depending on a full Prometheus client
+// library.
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/cbeuw/Cloak/internal/multiplex"
+)
+
+// Registry tracks the live sessions whose Metrics should be reported.
+type Registry struct {
+	mu       sync.Mutex
+	sessions map[int]*multiplex.Session
+}
+
+// NewRegistry returns an empty Registry ready to track sessions.
+func NewRegistry() *Registry {
+	return &Registry{sessions: make(map[int]*multiplex.Session)}
+}
+
+// Track adds sesh to the set reported on Handler's endpoint.
+func (r *Registry) Track(sesh *multiplex.Session) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sessions[sesh.ID()] = sesh
+}
+
+// Untrack removes a session once it's closed, so it stops showing up in
+// scrapes.
+func (r *Registry) Untrack(sesh *multiplex.Session) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.sessions, sesh.ID())
+}
+
+// Handler returns an http.Handler serving every tracked session's counters
+// and gauges in the Prometheus text exposition format. Mount it wherever
+// ck-client/ck-server expose their own HTTP endpoints, e.g. `mux.Handle("/metrics", registry.Handler())`.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+		r.mu.Lock()
+		ids := make([]int, 0, len(r.sessions))
+		for id := range r.sessions {
+			ids = append(ids, id)
+		}
+		sort.Ints(ids)
+
+		for _, id := range ids {
+			s := r.sessions[id].Metrics().Snapshot()
+			fmt.Fprintf(w, "cloak_session_streams_opened_total{session=\"%d\"} %d\n", id, s.StreamsOpened)
+			fmt.Fprintf(w, "cloak_session_streams_closed_total{session=\"%d\"} %d\n", id, s.StreamsClosed)
+			fmt.Fprintf(w, "cloak_session_streams_reset_total{session=\"%d\"} %d\n", id, s.StreamsReset)
+			fmt.Fprintf(w, "cloak_session_bytes_in_total{session=\"%d\"} %d\n", id, s.BytesIn)
+			fmt.Fprintf(w, "cloak_session_bytes_out_total{session=\"%d\"} %d\n", id, s.BytesOut)
+			fmt.Fprintf(w, "cloak_session_frame_decode_errors_total{session=\"%d\"} %d\n", id, s.FrameDecodeErrs)
+			fmt.Fprintf(w, "cloak_session_active_streams{session=\"%d\"} %d\n", id, s.ActiveStreams)
+			fmt.Fprintf(w, "cloak_session_connections{session=\"%d\"} %d\n", id, s.Connections)
+		}
+		r.mu.Unlock()
+	})
+}
@@ -0,0 +1,60 @@
+package metrics
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cbeuw/Cloak/internal/multiplex"
+)
+
+// newTestSession returns a minimally-wired Session backed by a net.Pipe, just
+// enough for Registry to track it and read its Metrics - the obfs/deobfs/
+// obfsedReader plumbing itself is irrelevant to what's under test here.
+func newTestSession(t *testing.T, id int) *multiplex.Session {
+	t.Helper()
+	conn, peer := net.Pipe()
+	t.Cleanup(func() { peer.Close() })
+
+	obfs := func(f *multiplex.Frame) []byte { return f.Payload }
+	deobfs := func(b []byte) *multiplex.Frame { return &multiplex.Frame{Payload: b} }
+	obfsedReader := func(c net.Conn, buf []byte) (int, error) { return c.Read(buf) }
+
+	sesh := multiplex.MakeSession(id, multiplex.SessionConfig{}, nil, conn, obfs, deobfs, obfsedReader)
+	t.Cleanup(func() { sesh.Close() })
+	return sesh
+}
+
+func TestRegistryTrackUntrack(t *testing.T) {
+	r := NewRegistry()
+	sesh := newTestSession(t, 1)
+
+	r.Track(sesh)
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if !strings.Contains(rec.Body.String(), `session="1"`) {
+		t.Fatalf("expected a tracked session to show up in the scrape, got:\n%s", rec.Body.String())
+	}
+
+	r.Untrack(sesh)
+	rec = httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if strings.Contains(rec.Body.String(), `session="1"`) {
+		t.Fatalf("expected an untracked session to disappear from the scrape, got:\n%s", rec.Body.String())
+	}
+}
+
+func TestHandlerEmptyRegistry(t *testing.T) {
+	r := NewRegistry()
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 from an empty registry's scrape, got %d", rec.Code)
+	}
+	if rec.Body.Len() != 0 {
+		t.Fatalf("expected an empty body for an empty registry, got:\n%s", rec.Body.String())
+	}
+}
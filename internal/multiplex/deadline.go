@@ -0,0 +1,66 @@
+package multiplex
+
+import (
+	"sync"
+	"time"
+)
+
+// errTimeout is returned by Stream.Read/Write when a deadline set with
+// SetDeadline/SetReadDeadline/SetWriteDeadline elapses. It implements
+// net.Error so callers doing the usual `if ne, ok := err.(net.Error);
+// ok && ne.Timeout()` check keep working.
+type errTimeout struct{}
+
+func (errTimeout) Error() string   { return "deadline exceeded" }
+func (errTimeout) Timeout() bool   { return true }
+func (errTimeout) Temporary() bool { return true }
+
+// deadline is lifted from the net.Pipe implementation in the standard
+// library: a channel that's closed once a deadline fires, re-armable by
+// resetting it, so Read/Write can select on it directly.
+type deadline struct {
+	mu     sync.Mutex
+	timer  *time.Timer
+	cancel chan struct{} // closed when the deadline fires; replaced by set only once closed
+}
+
+func makeDeadline() *deadline {
+	return &deadline{cancel: make(chan struct{})}
+}
+
+// set arms the deadline. A zero time.Time disarms it.
+func (d *deadline) set(t time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.timer != nil && !d.timer.Stop() {
+		<-d.cancel // the timer already fired; wait for it to close cancel
+	}
+	d.timer = nil
+	// Keep an open channel so a Read/Write already waiting on it sees the
+	// new deadline; only a fired (closed) one is swapped for a fresh one.
+	select {
+	case <-d.cancel:
+		d.cancel = make(chan struct{})
+	default:
+	}
+
+	if t.IsZero() {
+		return
+	}
+
+	until := time.Until(t)
+	cancel := d.cancel
+	if until <= 0 {
+		close(cancel)
+		return
+	}
+	d.timer = time.AfterFunc(until, func() { close(cancel) })
+}
+
+// wait returns a channel that's closed once the deadline fires.
+func (d *deadline) wait() chan struct{} {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.cancel
+}
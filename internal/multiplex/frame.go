@@ -0,0 +1,44 @@
+package multiplex
+
+// frameType identifies the purpose of a Frame. PSH carries application
+// data; the rest are control frames used to manage stream and session
+// lifecycle and flow control.
+type frameType uint8
+
+const (
+	typePSH      frameType = iota // application data
+	typeFIN                       // graceful close: no more data will be sent; the receiver drains buffered reads, then closes its side too
+	typeRST                       // hard reset: abandon the stream immediately, no draining
+	typeWNDUPD                    // flow-control window update, Payload is a little-endian uint32 increment
+	typeNOP                       // keepalive ping, StreamID is 0 and Payload is empty
+	typePRIORITY                  // stream priority update, Payload is weight(1) || dependency(4, little-endian)
+)
+
+// Frame is the unit of multiplexing. obfs turns a Frame into an obfuscated
+// TLS-shaped byte slice, deobfs does the reverse.
+type Frame struct {
+	Type     frameType
+	StreamID uint32
+	// PriorityHint is set on typePRIORITY frames (and may be set on any
+	// other frame type) so the remote peer's switchboard can mirror the
+	// sender's scheduling decision for its own outbound traffic on this
+	// stream, the way HTTP/2's PRIORITY flag works.
+	PriorityHint bool
+	Payload      []byte
+}
+
+func encodePriority(weight uint8, dependency uint32) []byte {
+	return []byte{weight, byte(dependency), byte(dependency >> 8), byte(dependency >> 16), byte(dependency >> 24)}
+}
+
+// decodePriority reports ok == false without touching weight/dependency if b
+// is too short to hold a priority payload, e.g. a truncated or malformed
+// PRIORITY frame from the wire.
+func decodePriority(b []byte) (weight uint8, dependency uint32, ok bool) {
+	if len(b) < 5 {
+		return 0, 0, false
+	}
+	weight = b[0]
+	dependency = uint32(b[1]) | uint32(b[2])<<8 | uint32(b[3])<<16 | uint32(b[4])<<24
+	return weight, dependency, true
+}
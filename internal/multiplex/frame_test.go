@@ -0,0 +1,20 @@
+package multiplex
+
+import "testing"
+
+// TestDecodePriorityRejectsTruncatedPayload guards against a short or
+// malformed PRIORITY payload (e.g. a truncated frame from the wire) causing
+// an index-out-of-range panic instead of being reported as undecodable.
+func TestDecodePriorityRejectsTruncatedPayload(t *testing.T) {
+	if _, _, ok := decodePriority([]byte{1, 2}); ok {
+		t.Fatalf("expected ok == false for a truncated payload")
+	}
+	if _, _, ok := decodePriority(nil); ok {
+		t.Fatalf("expected ok == false for a nil payload")
+	}
+
+	weight, dependency, ok := decodePriority(encodePriority(5, 42))
+	if !ok || weight != 5 || dependency != 42 {
+		t.Fatalf("expected (5, 42, true), got (%d, %d, %v)", weight, dependency, ok)
+	}
+}
@@ -0,0 +1,13 @@
+package multiplex
+
+import "log"
+
+// Logger is the minimal logging interface Session needs. *log.Logger
+// satisfies it, but it's just as easy to plug in a structured logger so
+// operators don't have to scrape log lines for session health.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// defaultLogger is used by MakeSession when its caller doesn't supply one.
+var defaultLogger Logger = log.New(log.Writer(), "", log.LstdFlags)
@@ -0,0 +1,55 @@
+package multiplex
+
+import "sync/atomic"
+
+// Metrics holds the Prometheus-style counters and gauges for one Session.
+// Every field is updated with atomic ops so a scraper can read a Snapshot
+// concurrently with the session serving traffic.
+type Metrics struct {
+	StreamsOpened   uint64
+	StreamsClosed   uint64
+	StreamsReset    uint64
+	BytesIn         uint64
+	BytesOut        uint64
+	FrameDecodeErrs uint64
+	ActiveStreams   int64
+	Connections     int64
+}
+
+func (m *Metrics) streamOpened() {
+	atomic.AddUint64(&m.StreamsOpened, 1)
+	atomic.AddInt64(&m.ActiveStreams, 1)
+}
+
+func (m *Metrics) streamClosed() {
+	atomic.AddUint64(&m.StreamsClosed, 1)
+	atomic.AddInt64(&m.ActiveStreams, -1)
+}
+
+func (m *Metrics) streamReset() {
+	atomic.AddUint64(&m.StreamsReset, 1)
+	atomic.AddInt64(&m.ActiveStreams, -1)
+}
+
+func (m *Metrics) bytesIn(n int)  { atomic.AddUint64(&m.BytesIn, uint64(n)) }
+func (m *Metrics) bytesOut(n int) { atomic.AddUint64(&m.BytesOut, uint64(n)) }
+
+func (m *Metrics) frameDecodeErr() { atomic.AddUint64(&m.FrameDecodeErrs, 1) }
+
+func (m *Metrics) connAdded()   { atomic.AddInt64(&m.Connections, 1) }
+func (m *Metrics) connRemoved() { atomic.AddInt64(&m.Connections, -1) }
+
+// Snapshot returns a point-in-time copy of m suitable for formatting and
+// exposing to a scraper.
+func (m *Metrics) Snapshot() Metrics {
+	return Metrics{
+		StreamsOpened:   atomic.LoadUint64(&m.StreamsOpened),
+		StreamsClosed:   atomic.LoadUint64(&m.StreamsClosed),
+		StreamsReset:    atomic.LoadUint64(&m.StreamsReset),
+		BytesIn:         atomic.LoadUint64(&m.BytesIn),
+		BytesOut:        atomic.LoadUint64(&m.BytesOut),
+		FrameDecodeErrs: atomic.LoadUint64(&m.FrameDecodeErrs),
+		ActiveStreams:   atomic.LoadInt64(&m.ActiveStreams),
+		Connections:     atomic.LoadInt64(&m.Connections),
+	}
+}
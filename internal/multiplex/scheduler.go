@@ -0,0 +1,289 @@
+package multiplex
+
+import "sync"
+
+// defaultStreamWeight is the weight a stream gets until SetPriority says
+// otherwise, mirroring HTTP/2's default stream weight of 16.
+const defaultStreamWeight = 16
+
+// Scheduler decides the order in which frames queued by a session's streams
+// are written to the wire. MakeSession defaults to NewWFQScheduler;
+// NewStrictPriorityScheduler and NewRoundRobinScheduler are provided as
+// drop-in alternatives for traffic mixes WFQ doesn't suit.
+type Scheduler interface {
+	// Submit queues f to be sent under its stream's current priority.
+	Submit(f *Frame)
+	// SetPriority updates a stream's weight (higher sends more per round)
+	// and the stream ID it's prioritised relative to: a stream with a
+	// dependency is only serviced once that stream's queue is empty,
+	// mirroring HTTP/2's default (non-exclusive) stream dependencies.
+	SetPriority(streamID uint32, weight uint8, dependency uint32)
+	// Pop blocks until a frame is ready to send, or returns nil once die is
+	// closed.
+	Pop(die <-chan struct{}) *Frame
+	// Remove evicts a stream's queue once it's closed, so a long-lived
+	// session doesn't accumulate an entry per stream ID it's ever seen.
+	Remove(streamID uint32)
+}
+
+type streamQueue struct {
+	weight     uint8
+	dependency uint32
+	frames     []*Frame
+	deficit    int
+	// closing is set by Remove when frames are still queued (typically the
+	// stream's own FIN/RST, submitted just before Remove is called); the
+	// queue is evicted once those frames are popped instead of being
+	// dropped along with them.
+	closing bool
+}
+
+// baseScheduler holds the bookkeeping every Scheduler implementation needs;
+// the schedulers differ only in how they pick which ready queue to pop from.
+type baseScheduler struct {
+	mu      sync.Mutex
+	ready   chan struct{}
+	queues  map[uint32]*streamQueue
+	rrOrder []uint32
+	rrPos   int
+}
+
+func newBaseScheduler() *baseScheduler {
+	return &baseScheduler{
+		ready:  make(chan struct{}, 1),
+		queues: make(map[uint32]*streamQueue),
+	}
+}
+
+func (b *baseScheduler) getOrCreate(id uint32) *streamQueue {
+	q, ok := b.queues[id]
+	if !ok {
+		q = &streamQueue{weight: defaultStreamWeight}
+		b.queues[id] = q
+		b.rrOrder = append(b.rrOrder, id)
+	}
+	return q
+}
+
+func (b *baseScheduler) Submit(f *Frame) {
+	b.mu.Lock()
+	q := b.getOrCreate(f.StreamID)
+	q.frames = append(q.frames, f)
+	b.mu.Unlock()
+	notify(b.ready)
+}
+
+func (b *baseScheduler) SetPriority(streamID uint32, weight uint8, dependency uint32) {
+	b.mu.Lock()
+	q := b.getOrCreate(streamID)
+	q.weight = weight
+	if b.wouldCycle(streamID, dependency) {
+		// A self-dependency or a cycle through other streams would make
+		// every stream in it permanently block on the others in
+		// popLocked; drop it down to "no dependency" instead.
+		dependency = 0
+	}
+	q.dependency = dependency
+	b.mu.Unlock()
+}
+
+// wouldCycle reports whether giving streamID the dependency chain starting
+// at dependency would loop back to streamID. dependency is attacker-
+// controlled (it comes straight off the wire via a PRIORITY frame), so this
+// must terminate even on adversarial input.
+func (b *baseScheduler) wouldCycle(streamID, dependency uint32) bool {
+	seen := map[uint32]bool{streamID: true}
+	for dependency != 0 {
+		if seen[dependency] {
+			return true
+		}
+		seen[dependency] = true
+		q, ok := b.queues[dependency]
+		if !ok {
+			return false
+		}
+		dependency = q.dependency
+	}
+	return false
+}
+
+func (b *baseScheduler) Remove(streamID uint32) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	q, ok := b.queues[streamID]
+	if !ok {
+		return
+	}
+	if len(q.frames) > 0 {
+		// Evicting now would drop whatever's still queued - typically the
+		// FIN/RST Close/Reset just submitted - before sendLoop gets to pop
+		// it. Defer eviction to popLocked, once it actually drains them.
+		q.closing = true
+		return
+	}
+	b.removeLocked(streamID)
+}
+
+// removeLocked evicts streamID's queue. Caller must hold b.mu.
+func (b *baseScheduler) removeLocked(streamID uint32) {
+	delete(b.queues, streamID)
+	for i, id := range b.rrOrder {
+		if id == streamID {
+			b.rrOrder = append(b.rrOrder[:i], b.rrOrder[i+1:]...)
+			if b.rrPos > i {
+				b.rrPos--
+			}
+			break
+		}
+	}
+	if len(b.rrOrder) == 0 {
+		b.rrPos = 0
+	} else {
+		b.rrPos %= len(b.rrOrder)
+	}
+}
+
+// blockedByDependency reports whether q must yield because the stream it
+// depends on (dependency 0 means none, matching HTTP/2's implicit root
+// stream) still has frames of its own queued. This assumes dependencies
+// don't form cycles, same as HTTP/2's own priority tree.
+func (b *baseScheduler) blockedByDependency(q *streamQueue) bool {
+	if q.dependency == 0 {
+		return false
+	}
+	dep, ok := b.queues[q.dependency]
+	return ok && len(dep.frames) > 0
+}
+
+// pop blocks calling popLocked (which must only inspect/mutate b's fields,
+// already under b.mu) until it returns a frame or die closes.
+func (b *baseScheduler) pop(die <-chan struct{}, popLocked func() *Frame) *Frame {
+	for {
+		b.mu.Lock()
+		f := popLocked()
+		if f != nil {
+			if q, ok := b.queues[f.StreamID]; ok && q.closing && len(q.frames) == 0 {
+				b.removeLocked(f.StreamID)
+			}
+		}
+		b.mu.Unlock()
+		if f != nil {
+			return f
+		}
+		select {
+		case <-die:
+			return nil
+		case <-b.ready:
+		}
+	}
+}
+
+// wfqScheduler approximates weighted fair queuing with deficit round robin:
+// each ready stream is visited in turn, credited deficit equal to its weight
+// once its deficit is spent, and allowed to send one frame (cost 1) per Pop
+// until its deficit runs out, at which point the next stream gets its turn.
+type wfqScheduler struct{ *baseScheduler }
+
+// NewWFQScheduler returns the default Scheduler: streams are serviced in a
+// weighted round robin so a high-weight interactive stream gets proportionally
+// more turns than a low-weight bulk transfer sharing the same session.
+func NewWFQScheduler() Scheduler {
+	return &wfqScheduler{newBaseScheduler()}
+}
+
+func (s *wfqScheduler) Pop(die <-chan struct{}) *Frame {
+	return s.pop(die, s.popLocked)
+}
+
+func (s *wfqScheduler) popLocked() *Frame {
+	n := len(s.rrOrder)
+	for i := 0; i < n; i++ {
+		id := s.rrOrder[s.rrPos]
+		q := s.queues[id]
+		if len(q.frames) == 0 || s.blockedByDependency(q) {
+			q.deficit = 0
+			s.rrPos = (s.rrPos + 1) % n
+			continue
+		}
+		if q.deficit < 1 {
+			weight := int(q.weight)
+			if weight == 0 {
+				weight = 1
+			}
+			q.deficit += weight
+		}
+		q.deficit--
+		if q.deficit == 0 {
+			s.rrPos = (s.rrPos + 1) % n
+		}
+		f := q.frames[0]
+		q.frames = q.frames[1:]
+		return f
+	}
+	return nil
+}
+
+// strictPriorityScheduler always sends from the highest-weight ready stream,
+// starving lower-weight streams outright until it empties.
+type strictPriorityScheduler struct{ *baseScheduler }
+
+// NewStrictPriorityScheduler returns a Scheduler where a stream's weight is
+// an absolute priority: as long as a higher-weight stream has data queued,
+// no lower-weight stream is serviced.
+func NewStrictPriorityScheduler() Scheduler {
+	return &strictPriorityScheduler{newBaseScheduler()}
+}
+
+func (s *strictPriorityScheduler) Pop(die <-chan struct{}) *Frame {
+	return s.pop(die, s.popLocked)
+}
+
+func (s *strictPriorityScheduler) popLocked() *Frame {
+	var best *streamQueue
+	for _, id := range s.rrOrder {
+		q := s.queues[id]
+		if len(q.frames) == 0 || s.blockedByDependency(q) {
+			continue
+		}
+		if best == nil || q.weight > best.weight {
+			best = q
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	f := best.frames[0]
+	best.frames = best.frames[1:]
+	return f
+}
+
+// roundRobinScheduler ignores weight entirely and cycles through ready
+// streams one frame at a time.
+type roundRobinScheduler struct{ *baseScheduler }
+
+// NewRoundRobinScheduler returns a Scheduler that gives every stream an
+// equal turn regardless of SetPriority, for traffic mixes where weighting
+// isn't worth the bookkeeping.
+func NewRoundRobinScheduler() Scheduler {
+	return &roundRobinScheduler{newBaseScheduler()}
+}
+
+func (s *roundRobinScheduler) Pop(die <-chan struct{}) *Frame {
+	return s.pop(die, s.popLocked)
+}
+
+func (s *roundRobinScheduler) popLocked() *Frame {
+	n := len(s.rrOrder)
+	for i := 0; i < n; i++ {
+		id := s.rrOrder[s.rrPos]
+		s.rrPos = (s.rrPos + 1) % n
+		q := s.queues[id]
+		if len(q.frames) == 0 || s.blockedByDependency(q) {
+			continue
+		}
+		f := q.frames[0]
+		q.frames = q.frames[1:]
+		return f
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package multiplex
+
+import "testing"
+
+// TestSchedulerRemoveEvictsQueue guards against regressing to leaking a
+// streamQueue per stream ID forever: after submitting then removing N
+// streams, both queues and rrOrder must shrink back to empty instead of
+// growing unboundedly as streams come and go.
+func TestSchedulerRemoveEvictsQueue(t *testing.T) {
+	s := NewWFQScheduler().(*wfqScheduler)
+
+	const n = 1000
+	for id := uint32(1); id <= n; id++ {
+		s.Submit(&Frame{Type: typePSH, StreamID: id, Payload: []byte("x")})
+	}
+	if got := len(s.queues); got != n {
+		t.Fatalf("expected %d queues after submit, got %d", n, got)
+	}
+
+	die := make(chan struct{})
+	for i := 0; i < n; i++ {
+		if f := s.Pop(die); f == nil {
+			t.Fatalf("expected a frame, got nil at i=%d", i)
+		}
+	}
+	for id := uint32(1); id <= n; id++ {
+		s.Remove(id)
+	}
+
+	if got := len(s.queues); got != 0 {
+		t.Fatalf("expected 0 queues after removal, got %d", got)
+	}
+	if got := len(s.rrOrder); got != 0 {
+		t.Fatalf("expected 0 rrOrder entries after removal, got %d", got)
+	}
+}
+
+// TestSchedulerDependencyBlocking checks that a stream set to depend on
+// another isn't popped while its dependency still has frames queued, and
+// becomes eligible again once the dependency drains.
+func TestSchedulerDependencyBlocking(t *testing.T) {
+	s := NewRoundRobinScheduler().(*roundRobinScheduler)
+
+	s.SetPriority(2, defaultStreamWeight, 1) // stream 2 depends on stream 1
+	s.Submit(&Frame{Type: typePSH, StreamID: 1, Payload: []byte("parent")})
+	s.Submit(&Frame{Type: typePSH, StreamID: 2, Payload: []byte("child")})
+
+	die := make(chan struct{})
+	f := s.Pop(die)
+	if f == nil || f.StreamID != 1 {
+		t.Fatalf("expected the dependency's frame first, got %+v", f)
+	}
+
+	f = s.Pop(die)
+	if f == nil || f.StreamID != 2 {
+		t.Fatalf("expected the dependent stream's frame once its dependency drained, got %+v", f)
+	}
+}
+
+// TestSchedulerRemoveDoesNotDropPendingFrame guards against the eviction in
+// Remove dropping a frame that was submitted but not yet popped - the
+// common case being Stream.Close/Reset submitting a FIN/RST and then
+// immediately calling Remove, racing sendLoop's Pop.
+func TestSchedulerRemoveDoesNotDropPendingFrame(t *testing.T) {
+	s := NewWFQScheduler().(*wfqScheduler)
+
+	s.Submit(&Frame{Type: typeFIN, StreamID: 1})
+	s.Remove(1) // as if Close() ran before sendLoop got to Pop
+
+	die := make(chan struct{})
+	f := s.Pop(die)
+	if f == nil || f.Type != typeFIN || f.StreamID != 1 {
+		t.Fatalf("expected the pending FIN frame to still be delivered, got %+v", f)
+	}
+
+	if _, ok := s.queues[1]; ok {
+		t.Fatalf("expected queue 1 to be evicted once its pending frame was popped")
+	}
+}
+
+// TestSchedulerRejectsDependencyCycle checks that a self- or mutual-
+// dependency (which an adversarial peer can request via a PRIORITY frame)
+// is dropped instead of permanently blocking the streams involved.
+func TestSchedulerRejectsDependencyCycle(t *testing.T) {
+	s := NewRoundRobinScheduler().(*roundRobinScheduler)
+
+	s.SetPriority(1, defaultStreamWeight, 1) // self-dependency
+	s.Submit(&Frame{Type: typePSH, StreamID: 1, Payload: []byte("a")})
+	die := make(chan struct{})
+	if f := s.Pop(die); f == nil || f.StreamID != 1 {
+		t.Fatalf("self-dependency should have been dropped, stream never popped: %+v", f)
+	}
+
+	s.SetPriority(2, defaultStreamWeight, 3) // 2 -> 3
+	s.SetPriority(3, defaultStreamWeight, 2) // 3 -> 2, would cycle
+	s.Submit(&Frame{Type: typePSH, StreamID: 2, Payload: []byte("b")})
+	s.Submit(&Frame{Type: typePSH, StreamID: 3, Payload: []byte("c")})
+	for i := 0; i < 2; i++ {
+		if f := s.Pop(die); f == nil {
+			t.Fatalf("mutual dependency should have been dropped, got nil frame at i=%d", i)
+		}
+	}
+}
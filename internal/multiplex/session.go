@@ -2,21 +2,77 @@ package multiplex
 
 import (
 	"errors"
-	"log"
 	"net"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
 const (
 	errBrokenSession        = "broken session"
 	errRepeatSessionClosing = "trying to close a closed session"
+	errTooManyStreams       = "too many streams open"
 	// Copied from smux
 	acceptBacklog = 1024
 
 	closeBacklog = 512
+
+	// defaultMaxStreams is the cap on concurrently open streams per session
+	// when SessionConfig doesn't specify one.
+	defaultMaxStreams = 1024
+
+	// defaultKeepAliveInterval is how often a NOP frame is sent to prove the
+	// underlying connections are still alive.
+	defaultKeepAliveInterval = 30 * time.Second
+	// defaultIdleTimeout is how long a session tolerates total silence from
+	// its peer (no frame of any kind) before declaring it dead and closing.
+	defaultIdleTimeout = 90 * time.Second
 )
 
+// SessionConfig carries the tunables for MakeSession. A zero value is
+// replaced by sane defaults.
+type SessionConfig struct {
+	// InitialRecvWindow is the per-stream receive window advertised to the
+	// peer, in bytes. Defaults to defaultRecvWindow (256 KiB).
+	InitialRecvWindow uint32
+	// MaxStreams caps how many streams this session will keep open at once.
+	// OpenStream and incoming stream creation are rejected past this limit.
+	// Defaults to defaultMaxStreams.
+	MaxStreams int
+	// KeepAliveInterval is how often a NOP frame is sent to the peer. Set to
+	// a negative value to disable keepalives entirely. Defaults to
+	// defaultKeepAliveInterval.
+	KeepAliveInterval time.Duration
+	// IdleTimeout is how long the session waits without receiving any frame
+	// before it considers the underlying connection dead and closes itself.
+	// It is checked once per KeepAliveInterval by the keepalive goroutine, so
+	// it is not enforced at all when keepalives are disabled. Defaults to
+	// defaultIdleTimeout.
+	IdleTimeout time.Duration
+	// Scheduler picks the order in which queued frames from different
+	// streams are written to the wire. Defaults to NewWFQScheduler().
+	Scheduler Scheduler
+}
+
+func (c SessionConfig) withDefaults() SessionConfig {
+	if c.InitialRecvWindow == 0 {
+		c.InitialRecvWindow = defaultRecvWindow
+	}
+	if c.MaxStreams == 0 {
+		c.MaxStreams = defaultMaxStreams
+	}
+	if c.KeepAliveInterval == 0 {
+		c.KeepAliveInterval = defaultKeepAliveInterval
+	}
+	if c.IdleTimeout == 0 {
+		c.IdleTimeout = defaultIdleTimeout
+	}
+	if c.Scheduler == nil {
+		c.Scheduler = NewWFQScheduler()
+	}
+	return c
+}
+
 type Session struct {
 	id int
 
@@ -35,6 +91,20 @@ type Session struct {
 	// Switchboard manages all connections to remote
 	sb *switchboard
 
+	logger  Logger
+	metrics *Metrics
+
+	initialRecvWindow uint32
+	maxStreams        int
+	keepAliveInterval time.Duration
+	idleTimeout       time.Duration
+	scheduler         Scheduler
+
+	// lastActive is a unix nanosecond timestamp updated every time a frame
+	// of any kind is received, used by the keepalive goroutine to detect a
+	// dead underlying connection.
+	lastActive int64
+
 	// For accepting new streams
 	acceptCh chan *Stream
 	// Once a stream.Close is called, it sends its streamID to this channel
@@ -47,33 +117,92 @@ type Session struct {
 	closing  bool
 }
 
-// 1 conn is needed to make a session
-func MakeSession(id int, conn net.Conn, obfs func(*Frame) []byte, deobfs func([]byte) *Frame, obfsedReader func(net.Conn, []byte) (int, error)) *Session {
+// 1 conn is needed to make a session. logger may be nil, in which case
+// Session logs to the standard logger as before.
+func MakeSession(id int, config SessionConfig, logger Logger, conn net.Conn, obfs func(*Frame) []byte, deobfs func([]byte) *Frame, obfsedReader func(net.Conn, []byte) (int, error)) *Session {
+	config = config.withDefaults()
+	if logger == nil {
+		logger = defaultLogger
+	}
 	sesh := &Session{
-		id:           id,
-		obfs:         obfs,
-		deobfs:       deobfs,
-		obfsedReader: obfsedReader,
-		nextStreamID: 1,
-		streams:      make(map[uint32]*Stream),
-		acceptCh:     make(chan *Stream, acceptBacklog),
-		closeQCh:     make(chan uint32, closeBacklog),
+		id:                id,
+		obfs:              obfs,
+		deobfs:            deobfs,
+		obfsedReader:      obfsedReader,
+		nextStreamID:      1,
+		logger:            logger,
+		metrics:           &Metrics{},
+		initialRecvWindow: config.InitialRecvWindow,
+		maxStreams:        config.MaxStreams,
+		keepAliveInterval: config.KeepAliveInterval,
+		idleTimeout:       config.IdleTimeout,
+		scheduler:         config.Scheduler,
+		streams:           make(map[uint32]*Stream),
+		acceptCh:          make(chan *Stream, acceptBacklog),
+		closeQCh:          make(chan uint32, closeBacklog),
+		die:               make(chan struct{}),
 	}
+	sesh.markActive()
 	sesh.sb = makeSwitchboard(conn, sesh)
+	if sesh.keepAliveInterval > 0 {
+		go sesh.keepaliveLoop()
+	}
 	return sesh
 }
 
+// ID returns the session's identifier, primarily so a metrics reporter can
+// label a Session's stats without reaching into its internals.
+func (sesh *Session) ID() int { return sesh.id }
+
+// Metrics returns the live counters and gauges for this session. The
+// returned pointer is stable for the session's lifetime and safe to read
+// concurrently via Metrics.Snapshot.
+func (sesh *Session) Metrics() *Metrics { return sesh.metrics }
+
+// markActive records that a frame was just received from the peer.
+func (sesh *Session) markActive() {
+	atomic.StoreInt64(&sesh.lastActive, time.Now().UnixNano())
+}
+
+// keepaliveLoop periodically pings the peer and closes the session if
+// nothing at all has been heard back within idleTimeout.
+func (sesh *Session) keepaliveLoop() {
+	ticker := time.NewTicker(sesh.keepAliveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-sesh.die:
+			return
+		case <-ticker.C:
+			last := time.Unix(0, atomic.LoadInt64(&sesh.lastActive))
+			if time.Since(last) > sesh.idleTimeout {
+				sesh.Close()
+				return
+			}
+			sesh.sb.ping()
+		}
+	}
+}
+
 func (sesh *Session) AddConnection(conn net.Conn) {
 	sesh.sb.newConnCh <- conn
 }
 
 func (sesh *Session) OpenStream() (*Stream, error) {
+	sesh.streamsM.Lock()
+	if len(sesh.streams) >= sesh.maxStreams {
+		sesh.streamsM.Unlock()
+		return nil, errors.New(errTooManyStreams)
+	}
+	sesh.streamsM.Unlock()
+
 	id := atomic.AddUint32(&sesh.nextStreamID, 1)
 	id -= 1 // Because atomic.AddUint32 returns the value after incrementation
 	stream := makeStream(id, sesh)
 	sesh.streamsM.Lock()
 	sesh.streams[id] = stream
 	sesh.streamsM.Unlock()
+	sesh.metrics.streamOpened()
 	return stream, nil
 }
 
@@ -91,6 +220,7 @@ func (sesh *Session) delStream(id uint32) {
 	sesh.streamsM.Lock()
 	delete(sesh.streams, id)
 	sesh.streamsM.Unlock()
+	sesh.scheduler.Remove(id)
 }
 
 func (sesh *Session) isStream(id uint32) bool {
@@ -108,11 +238,17 @@ func (sesh *Session) getStream(id uint32) *Stream {
 
 // addStream is used when the remote opened a new stream and we got notified
 func (sesh *Session) addStream(id uint32) *Stream {
-	log.Printf("Adding stream %v", id)
-	stream := makeStream(id, sesh)
 	sesh.streamsM.Lock()
+	if len(sesh.streams) >= sesh.maxStreams {
+		sesh.streamsM.Unlock()
+		sesh.sb.send(&Frame{Type: typeRST, StreamID: id})
+		return nil
+	}
+	sesh.logger.Printf("Adding stream %v", id)
+	stream := makeStream(id, sesh)
 	sesh.streams[id] = stream
 	sesh.streamsM.Unlock()
+	sesh.metrics.streamOpened()
 	sesh.acceptCh <- stream
 	return stream
 }
@@ -126,6 +262,7 @@ func (sesh *Session) Close() error {
 	}
 	sesh.closing = true
 	close(sesh.die)
+	sesh.sb.close()
 	sesh.streamsM.Lock()
 	for id, stream := range sesh.streams {
 		// If we call stream.Close() here, streamsM will result in a deadlock
@@ -133,8 +270,10 @@ func (sesh *Session) Close() error {
 		// so we need to implement a method of stream that closes the stream without calling
 		// sesh.delStream
 		// This can also be seen in smux
-		go stream.closeNoDelMap()
+		go stream.closeNoDelMap(errors.New(errBrokenSession))
 		delete(sesh.streams, id)
+		sesh.scheduler.Remove(id)
+		sesh.metrics.streamReset()
 	}
 	sesh.streamsM.Unlock()
 
@@ -0,0 +1,79 @@
+package multiplex
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+// TestCloseDecrementsActiveStreams guards against the ActiveStreams gauge
+// never returning to zero after a session closes: Close tears down every
+// stream via closeNoDelMap directly, bypassing Stream.Close/Reset, so it
+// must poke the metrics hook itself.
+func TestCloseDecrementsActiveStreams(t *testing.T) {
+	sched := NewWFQScheduler()
+	sesh := &Session{
+		scheduler: sched,
+		metrics:   &Metrics{},
+		streams:   make(map[uint32]*Stream),
+		die:       make(chan struct{}),
+	}
+	sesh.sb = &switchboard{sesh: sesh, scheduler: sched}
+
+	const n = 10
+	for id := uint32(1); id <= n; id++ {
+		stream := makeStream(id, sesh)
+		sesh.streams[id] = stream
+		sesh.metrics.streamOpened()
+	}
+
+	if got := sesh.metrics.Snapshot().ActiveStreams; got != n {
+		t.Fatalf("expected %d active streams before Close, got %d", n, got)
+	}
+
+	if err := sesh.Close(); err != nil {
+		t.Fatalf("Close returned an error: %v", err)
+	}
+
+	if got := sesh.metrics.Snapshot().ActiveStreams; got != 0 {
+		t.Fatalf("expected 0 active streams after Close, got %d", got)
+	}
+}
+
+// TestCloseClosesSwitchboardConns guards against Session.Close leaking every
+// connection's recvLoop goroutine and socket fd forever: it must close each
+// conn the switchboard is tracking, including on the routine idle-timeout
+// path where keepaliveLoop calls Close on the peer's behalf.
+func TestCloseClosesSwitchboardConns(t *testing.T) {
+	sched := NewWFQScheduler()
+	sesh := &Session{
+		scheduler: sched,
+		metrics:   &Metrics{},
+		streams:   make(map[uint32]*Stream),
+		die:       make(chan struct{}),
+	}
+	clientConn, serverConn := net.Pipe()
+	defer serverConn.Close()
+	sesh.sb = &switchboard{sesh: sesh, scheduler: sched, conns: []net.Conn{clientConn}}
+
+	if err := sesh.Close(); err != nil {
+		t.Fatalf("Close returned an error: %v", err)
+	}
+
+	// net.Pipe has no read/write deadlines of its own, so a write on the
+	// still-open peer end blocking forever (instead of failing immediately
+	// with io.ErrClosedPipe) is how an unclosed conn would show up here.
+	done := make(chan error, 1)
+	go func() {
+		_, err := serverConn.Write([]byte("x"))
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatalf("expected a write on the peer end to fail once Close closed the conn")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected the conn to be closed by Session.Close, but it's still open")
+	}
+}
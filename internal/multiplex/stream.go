@@ -0,0 +1,314 @@
+package multiplex
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	errStreamClosed       = "stream closed"
+	errStreamReset        = "stream reset by peer"
+	errRecvWindowExceeded = "peer sent more data than its granted receive window allows"
+
+	// defaultRecvWindow is how many unread bytes of credit a stream advertises
+	// to its peer by default. Mirrors smux's default window.
+	defaultRecvWindow = 256 * 1024
+)
+
+// Stream is a multiplexed logical connection on top of a Session.
+type Stream struct {
+	id   uint32
+	sesh *Session
+
+	recvBufM sync.Mutex
+	recvBuf  []byte
+	// recvWindowRemaining is the receive-side mirror of sendWindow: how many
+	// more bytes of credit we've granted the peer that it hasn't used yet.
+	// recvDataFromRemote decrements it by the size of each PSH payload;
+	// grantRecvWindow (called once the application Read's the data back out)
+	// increments it by the same amount it hands the peer in a WNDUPD.
+	// Guarded by recvBufM since it's always updated alongside recvBuf.
+	recvWindowRemaining uint32
+	// newDataCh is signalled whenever recvBuf gains data or the stream closes,
+	// to wake up blocked Read calls.
+	newDataCh chan struct{}
+
+	// sendWindow is the credit the peer has granted us. Write blocks when it
+	// reaches zero and is replenished by an incoming WNDUPD.
+	sendWindow         uint32
+	sendWindowM        sync.Mutex
+	sendWindowNotifyCh chan struct{}
+
+	closedM     sync.Mutex
+	closed      bool
+	closeReason error
+
+	readDeadline  *deadline
+	writeDeadline *deadline
+
+	die chan struct{}
+}
+
+func makeStream(id uint32, sesh *Session) *Stream {
+	return &Stream{
+		id:                  id,
+		sesh:                sesh,
+		newDataCh:           make(chan struct{}, 1),
+		recvWindowRemaining: sesh.initialRecvWindow,
+		sendWindow:          sesh.initialRecvWindow,
+		sendWindowNotifyCh:  make(chan struct{}, 1),
+		readDeadline:        makeDeadline(),
+		writeDeadline:       makeDeadline(),
+		die:                 make(chan struct{}),
+	}
+}
+
+// recvDataFromRemote is called by the switchboard's receive loop when a PSH
+// frame for this stream arrives. A peer that ignores our WNDUPDs and sends
+// more than the window it was granted is resetting the whole point of flow
+// control (bounding our memory use), so it gets the stream reset instead of
+// having its data buffered anyway.
+func (stream *Stream) recvDataFromRemote(data []byte) {
+	stream.recvBufM.Lock()
+	if uint32(len(data)) > stream.recvWindowRemaining {
+		stream.recvBufM.Unlock()
+		if stream.closeNoDelMap(errors.New(errRecvWindowExceeded)) == nil {
+			stream.sesh.sb.send(&Frame{Type: typeRST, StreamID: stream.id})
+			stream.sesh.delStream(stream.id)
+			stream.sesh.metrics.streamReset()
+		}
+		return
+	}
+	stream.recvWindowRemaining -= uint32(len(data))
+	stream.recvBuf = append(stream.recvBuf, data...)
+	stream.recvBufM.Unlock()
+	stream.sesh.metrics.bytesIn(len(data))
+	notify(stream.newDataCh)
+}
+
+// recvWndUpdFromRemote is called when a WNDUPD frame for this stream arrives,
+// granting us more credit to Write with.
+func (stream *Stream) recvWndUpdFromRemote(increment uint32) {
+	stream.sendWindowM.Lock()
+	stream.sendWindow += increment
+	stream.sendWindowM.Unlock()
+	notify(stream.sendWindowNotifyCh)
+}
+
+func notify(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+func (stream *Stream) Read(buf []byte) (n int, err error) {
+	for {
+		stream.closedM.Lock()
+		reason := stream.closeReason
+		stream.closedM.Unlock()
+		if reason != nil {
+			// RST: surface the error immediately, without draining recvBuf.
+			return 0, reason
+		}
+
+		stream.recvBufM.Lock()
+		if len(stream.recvBuf) > 0 {
+			n = copy(buf, stream.recvBuf)
+			stream.recvBuf = stream.recvBuf[n:]
+			stream.recvBufM.Unlock()
+			stream.grantRecvWindow(uint32(n))
+			return n, nil
+		}
+		stream.recvBufM.Unlock()
+
+		if stream.isClosed() {
+			return 0, io.EOF
+		}
+
+		select {
+		case <-stream.die:
+			// A FIN lets us drain what's already in recvBuf (handled above)
+			// before reporting EOF; once closed and drained we fall through.
+			continue
+		case <-stream.newDataCh:
+		case <-stream.readDeadline.wait():
+			return 0, errTimeout{}
+		}
+	}
+}
+
+// grantRecvWindow is called after the application has consumed n bytes; it
+// tells the peer it may send n more bytes on this stream.
+func (stream *Stream) grantRecvWindow(n uint32) {
+	if n == 0 {
+		return
+	}
+	stream.recvBufM.Lock()
+	stream.recvWindowRemaining += n
+	stream.recvBufM.Unlock()
+	stream.sesh.sb.send(&Frame{
+		Type:     typeWNDUPD,
+		StreamID: stream.id,
+		Payload:  encodeUint32(n),
+	})
+}
+
+func (stream *Stream) Write(data []byte) (n int, err error) {
+	if stream.isClosed() {
+		return 0, errors.New(errStreamClosed)
+	}
+	for len(data) > 0 {
+		stream.sendWindowM.Lock()
+		avail := stream.sendWindow
+		stream.sendWindowM.Unlock()
+
+		if avail == 0 {
+			select {
+			case <-stream.die:
+				return n, errors.New(errStreamClosed)
+			case <-stream.sendWindowNotifyCh:
+				continue
+			case <-stream.writeDeadline.wait():
+				return n, errTimeout{}
+			}
+		}
+
+		chunk := data
+		if uint32(len(chunk)) > avail {
+			chunk = chunk[:avail]
+		}
+
+		stream.sesh.sb.send(&Frame{
+			Type:     typePSH,
+			StreamID: stream.id,
+			Payload:  chunk,
+		})
+		stream.sesh.metrics.bytesOut(len(chunk))
+
+		stream.sendWindowM.Lock()
+		stream.sendWindow -= uint32(len(chunk))
+		stream.sendWindowM.Unlock()
+
+		n += len(chunk)
+		data = data[len(chunk):]
+	}
+	return n, nil
+}
+
+func (stream *Stream) isClosed() bool {
+	stream.closedM.Lock()
+	defer stream.closedM.Unlock()
+	return stream.closed
+}
+
+// closeNoDelMap closes the stream without telling the session to remove it
+// from its stream map. Used when the session itself is tearing down, since
+// it already holds streamsM and is deleting entries itself. reason is nil
+// for a graceful FIN (Read drains buffered data before returning io.EOF) or
+// non-nil for a RST (Read returns reason immediately).
+func (stream *Stream) closeNoDelMap(reason error) error {
+	stream.closedM.Lock()
+	if stream.closed {
+		stream.closedM.Unlock()
+		return errors.New(errStreamClosed)
+	}
+	stream.closed = true
+	stream.closeReason = reason
+	stream.closedM.Unlock()
+	close(stream.die)
+	return nil
+}
+
+// Close gracefully closes the stream, sending a FIN so the peer can drain
+// its buffered reads before it sees EOF.
+func (stream *Stream) Close() error {
+	err := stream.closeNoDelMap(nil)
+	if err != nil {
+		return err
+	}
+	stream.sesh.sb.send(&Frame{
+		Type:     typeFIN,
+		StreamID: stream.id,
+	})
+	stream.sesh.delStream(stream.id)
+	stream.sesh.metrics.streamClosed()
+	return nil
+}
+
+// Reset aborts the stream immediately on both ends, surfacing as an error
+// on any pending or future Read instead of draining and returning io.EOF,
+// unlike the graceful Close. Use it when the application itself detects a
+// failure (e.g. the other side of a proxied connection broke) and wants
+// that to show up as an error on the peer's Read rather than a clean EOF.
+func (stream *Stream) Reset() error {
+	err := stream.closeNoDelMap(errors.New(errStreamReset))
+	if err != nil {
+		return err
+	}
+	stream.sesh.sb.send(&Frame{
+		Type:     typeRST,
+		StreamID: stream.id,
+	})
+	stream.sesh.delStream(stream.id)
+	stream.sesh.metrics.streamReset()
+	return nil
+}
+
+// SetDeadline implements net.Conn.
+func (stream *Stream) SetDeadline(t time.Time) error {
+	stream.readDeadline.set(t)
+	stream.writeDeadline.set(t)
+	return nil
+}
+
+// SetReadDeadline implements net.Conn.
+func (stream *Stream) SetReadDeadline(t time.Time) error {
+	stream.readDeadline.set(t)
+	return nil
+}
+
+// SetWriteDeadline implements net.Conn.
+func (stream *Stream) SetWriteDeadline(t time.Time) error {
+	stream.writeDeadline.set(t)
+	return nil
+}
+
+// SetPriority mirrors HTTP/2 stream prioritisation: weight controls this
+// stream's share of the session's bandwidth relative to its siblings
+// (higher sends more), and dependency names the stream it's prioritised
+// under. It also sends a PRIORITY frame so the remote peer's scheduler
+// mirrors the same decision for its own outbound traffic on this stream.
+func (stream *Stream) SetPriority(weight uint8, dependency uint32) {
+	stream.sesh.sb.scheduler.SetPriority(stream.id, weight, dependency)
+	stream.sesh.sb.send(&Frame{
+		Type:         typePRIORITY,
+		StreamID:     stream.id,
+		PriorityHint: true,
+		Payload:      encodePriority(weight, dependency),
+	})
+}
+
+// LocalAddr implements net.Conn. A Stream doesn't have its own address, so
+// it defers to the underlying session's first connection.
+func (stream *Stream) LocalAddr() net.Addr { return stream.sesh.sb.localAddr() }
+
+// RemoteAddr implements net.Conn.
+func (stream *Stream) RemoteAddr() net.Addr { return stream.sesh.sb.remoteAddr() }
+
+func encodeUint32(v uint32) []byte {
+	return []byte{byte(v), byte(v >> 8), byte(v >> 16), byte(v >> 24)}
+}
+
+// decodeUint32 reports ok == false without returning a value if b is too
+// short to hold a uint32, e.g. a truncated or malformed WNDUPD frame from
+// the wire.
+func decodeUint32(b []byte) (v uint32, ok bool) {
+	if len(b) < 4 {
+		return 0, false
+	}
+	return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16 | uint32(b[3])<<24, true
+}
@@ -0,0 +1,142 @@
+package multiplex
+
+import (
+	"io"
+	"testing"
+)
+
+// recordingScheduler captures submitted frames instead of writing them
+// anywhere, so grantRecvWindow's output can be inspected directly.
+type recordingScheduler struct {
+	*baseScheduler
+	submitted []*Frame
+}
+
+func newRecordingScheduler() *recordingScheduler {
+	return &recordingScheduler{baseScheduler: newBaseScheduler()}
+}
+
+func (s *recordingScheduler) Submit(f *Frame) {
+	s.submitted = append(s.submitted, f)
+}
+
+func (s *recordingScheduler) Pop(die <-chan struct{}) *Frame { return nil }
+
+// TestGrantRecvWindowSendsDelta guards against regressing to sending a
+// cumulative recvWindow total instead of the bytes just freed by Read: two
+// consecutive grants of 1000 bytes must each produce a WNDUPD of exactly
+// 1000, not a running total.
+func TestGrantRecvWindowSendsDelta(t *testing.T) {
+	sched := newRecordingScheduler()
+	sesh := &Session{scheduler: sched}
+	sesh.sb = &switchboard{sesh: sesh, scheduler: sched}
+	stream := makeStream(1, sesh)
+
+	stream.grantRecvWindow(1000)
+	stream.grantRecvWindow(1000)
+
+	if len(sched.submitted) != 2 {
+		t.Fatalf("expected 2 WNDUPD frames, got %d", len(sched.submitted))
+	}
+	for i, f := range sched.submitted {
+		if f.Type != typeWNDUPD {
+			t.Fatalf("frame %d: expected typeWNDUPD, got %v", i, f.Type)
+		}
+		got, ok := decodeUint32(f.Payload)
+		if !ok || got != 1000 {
+			t.Fatalf("frame %d: expected increment 1000, got %d (ok=%v)", i, got, ok)
+		}
+	}
+}
+
+// TestDecodeUint32RejectsTruncatedPayload guards against a short or
+// malformed WNDUPD payload (e.g. a truncated frame from the wire) causing an
+// index-out-of-range panic instead of being reported as undecodable.
+func TestDecodeUint32RejectsTruncatedPayload(t *testing.T) {
+	if _, ok := decodeUint32([]byte{1, 2}); ok {
+		t.Fatalf("expected ok == false for a truncated payload")
+	}
+	if _, ok := decodeUint32(nil); ok {
+		t.Fatalf("expected ok == false for a nil payload")
+	}
+
+	got, ok := decodeUint32(encodeUint32(1234))
+	if !ok || got != 1234 {
+		t.Fatalf("expected (1234, true), got (%d, %v)", got, ok)
+	}
+}
+
+// TestGrantRecvWindowSkipsZero ensures a zero-byte read (e.g. Read returning
+// early) doesn't send a useless WNDUPD.
+func TestGrantRecvWindowSkipsZero(t *testing.T) {
+	sched := newRecordingScheduler()
+	sesh := &Session{scheduler: sched}
+	sesh.sb = &switchboard{sesh: sesh, scheduler: sched}
+	stream := makeStream(1, sesh)
+
+	stream.grantRecvWindow(0)
+
+	if len(sched.submitted) != 0 {
+		t.Fatalf("expected no frames sent for a zero grant, got %d", len(sched.submitted))
+	}
+}
+
+// TestRecvDataFromRemoteEnforcesWindow guards against flow control being
+// sender-enforced only: a peer that ignores WNDUPDs and keeps sending past
+// the window it was granted must have its stream reset instead of growing
+// recvBuf without bound.
+func TestRecvDataFromRemoteEnforcesWindow(t *testing.T) {
+	sched := newRecordingScheduler()
+	sesh := &Session{scheduler: sched, metrics: &Metrics{}, streams: make(map[uint32]*Stream), initialRecvWindow: 10}
+	sesh.sb = &switchboard{sesh: sesh, scheduler: sched}
+	stream := makeStream(1, sesh)
+	sesh.streams[1] = stream
+
+	stream.recvDataFromRemote(make([]byte, 10)) // exactly at the window, should be accepted
+	if got := len(stream.recvBuf); got != 10 {
+		t.Fatalf("expected the in-window send to be buffered, got %d bytes buffered", got)
+	}
+
+	stream.recvDataFromRemote(make([]byte, 1)) // any more without a WNDUPD is an oversend
+
+	if len(sched.submitted) != 1 || sched.submitted[0].Type != typeRST {
+		t.Fatalf("expected the oversending stream to be RST, got %+v", sched.submitted)
+	}
+	if _, ok := sesh.streams[1]; ok {
+		t.Fatalf("expected the oversending stream to be removed from the session")
+	}
+	if got := sesh.metrics.Snapshot().StreamsReset; got != 1 {
+		t.Fatalf("expected StreamsReset to be 1, got %d", got)
+	}
+}
+
+// TestStreamReset checks that an application calling the exported Reset
+// gets RST semantics: an immediate error on Read (rather than io.EOF), an
+// RST frame sent to the peer, and the usual stream teardown bookkeeping.
+func TestStreamReset(t *testing.T) {
+	sched := newRecordingScheduler()
+	sesh := &Session{scheduler: sched, metrics: &Metrics{}, streams: make(map[uint32]*Stream)}
+	sesh.sb = &switchboard{sesh: sesh, scheduler: sched}
+	stream := makeStream(1, sesh)
+	sesh.streams[1] = stream
+
+	if err := stream.Reset(); err != nil {
+		t.Fatalf("Reset returned an error: %v", err)
+	}
+
+	if len(sched.submitted) != 1 || sched.submitted[0].Type != typeRST {
+		t.Fatalf("expected a single RST frame to be sent, got %+v", sched.submitted)
+	}
+
+	_, err := stream.Read(make([]byte, 16))
+	if err == nil || err == io.EOF {
+		t.Fatalf("expected Read to surface the reset immediately, got %v", err)
+	}
+
+	if got := sesh.metrics.Snapshot().StreamsReset; got != 1 {
+		t.Fatalf("expected StreamsReset to be 1, got %d", got)
+	}
+	if _, ok := sesh.streams[1]; ok {
+		t.Fatalf("expected Reset to remove the stream from the session")
+	}
+}
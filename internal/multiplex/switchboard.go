@@ -0,0 +1,187 @@
+package multiplex
+
+import (
+	"errors"
+	"net"
+	"sync"
+)
+
+// switchboard manages all the underlying connections to the remote and
+// multiplexes frames for every Stream of a Session across them.
+type switchboard struct {
+	sesh *Session
+
+	connsM sync.Mutex
+	conns  []net.Conn
+
+	// newConnCh is used by Session.AddConnection to hand a freshly dialed
+	// connection to the switchboard.
+	newConnCh chan net.Conn
+
+	// scheduler orders frames from competing streams onto the wire; see
+	// Scheduler.
+	scheduler Scheduler
+}
+
+func makeSwitchboard(conn net.Conn, sesh *Session) *switchboard {
+	sb := &switchboard{
+		sesh:      sesh,
+		conns:     []net.Conn{conn},
+		newConnCh: make(chan net.Conn, 16),
+		scheduler: sesh.scheduler,
+	}
+	sesh.metrics.connAdded()
+	go sb.acceptNewConns()
+	go sb.sendLoop()
+	go sb.recvLoop(conn)
+	return sb
+}
+
+func (sb *switchboard) acceptNewConns() {
+	for {
+		select {
+		case <-sb.sesh.die:
+			return
+		case conn := <-sb.newConnCh:
+			sb.connsM.Lock()
+			sb.conns = append(sb.conns, conn)
+			sb.connsM.Unlock()
+			sb.sesh.metrics.connAdded()
+			go sb.recvLoop(conn)
+		}
+	}
+}
+
+// send hands a frame to the scheduler to be written to the remote in turn.
+func (sb *switchboard) send(f *Frame) {
+	sb.scheduler.Submit(f)
+}
+
+// close closes every underlying connection the switchboard is tracking, to
+// unblock each one's recvLoop (blocked on a read) so it can return and be
+// cleaned up. Called once by Session.Close as part of session teardown.
+func (sb *switchboard) close() {
+	sb.connsM.Lock()
+	defer sb.connsM.Unlock()
+	for _, conn := range sb.conns {
+		_ = conn.Close()
+	}
+}
+
+func (sb *switchboard) pickConn() net.Conn {
+	sb.connsM.Lock()
+	defer sb.connsM.Unlock()
+	return sb.conns[0]
+}
+
+func (sb *switchboard) localAddr() net.Addr {
+	if conn := sb.pickConn(); conn != nil {
+		return conn.LocalAddr()
+	}
+	return nil
+}
+
+func (sb *switchboard) remoteAddr() net.Addr {
+	if conn := sb.pickConn(); conn != nil {
+		return conn.RemoteAddr()
+	}
+	return nil
+}
+
+// ping sends a keepalive frame through the switchboard.
+func (sb *switchboard) ping() {
+	sb.send(&Frame{Type: typeNOP})
+}
+
+func (sb *switchboard) sendLoop() {
+	for {
+		f := sb.scheduler.Pop(sb.sesh.die)
+		if f == nil {
+			return
+		}
+		conn := sb.pickConn()
+		if conn == nil {
+			continue
+		}
+		_, _ = conn.Write(sb.sesh.obfs(f))
+	}
+}
+
+func (sb *switchboard) recvLoop(conn net.Conn) {
+	defer sb.sesh.metrics.connRemoved()
+	buf := make([]byte, 65536)
+	for {
+		n, err := sb.sesh.obfsedReader(conn, buf)
+		if err != nil {
+			return
+		}
+		f := sb.sesh.deobfs(buf[:n])
+		if f == nil {
+			sb.sesh.metrics.frameDecodeErr()
+			continue
+		}
+		sb.dispatch(f)
+	}
+}
+
+func (sb *switchboard) dispatch(f *Frame) {
+	sb.sesh.markActive()
+
+	if f.Type == typeNOP {
+		// Keepalive: receipt alone is enough to prove the connection is alive.
+		return
+	}
+
+	if f.Type == typePRIORITY {
+		// Mirror the sender's scheduling decision for our own outbound
+		// traffic on this stream. Ignore it for a stream we don't know
+		// about: unlike PSH this never creates the stream, so
+		// acting on it would let a peer grow the scheduler's queues
+		// without bound just by naming streams that don't exist.
+		if sb.sesh.isStream(f.StreamID) {
+			weight, dependency, ok := decodePriority(f.Payload)
+			if !ok {
+				sb.sesh.metrics.frameDecodeErr()
+				return
+			}
+			sb.scheduler.SetPriority(f.StreamID, weight, dependency)
+		}
+		return
+	}
+
+	stream := sb.sesh.getStream(f.StreamID)
+	if stream == nil {
+		// Only PSH originates a stream. WNDUPD (like FIN/RST) for a stream we
+		// don't know about is dropped instead of fabricating one: ordinary
+		// reordering between connections gives no cross-direction ordering
+		// guarantee, so a WNDUPD can legitimately race ahead of the PSH that
+		// would have created the stream.
+		if f.Type != typePSH {
+			return
+		}
+		stream = sb.sesh.addStream(f.StreamID)
+		if stream == nil { // rejected for exceeding maxStreams, RST already sent
+			return
+		}
+	}
+
+	switch f.Type {
+	case typePSH:
+		stream.recvDataFromRemote(f.Payload)
+	case typeWNDUPD:
+		increment, ok := decodeUint32(f.Payload)
+		if !ok {
+			sb.sesh.metrics.frameDecodeErr()
+			return
+		}
+		stream.recvWndUpdFromRemote(increment)
+	case typeFIN:
+		_ = stream.closeNoDelMap(nil)
+		sb.sesh.delStream(f.StreamID)
+		sb.sesh.metrics.streamClosed()
+	case typeRST:
+		_ = stream.closeNoDelMap(errors.New(errStreamReset))
+		sb.sesh.delStream(f.StreamID)
+		sb.sesh.metrics.streamReset()
+	}
+}
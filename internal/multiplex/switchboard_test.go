@@ -0,0 +1,44 @@
+package multiplex
+
+import "testing"
+
+// TestDispatchPriorityIgnoresUnknownStream guards against a peer growing the
+// scheduler's queues without bound by sending PRIORITY frames for stream IDs
+// that were never opened: unlike PSH, PRIORITY never creates the
+// stream, so it must not create a scheduler queue either.
+func TestDispatchPriorityIgnoresUnknownStream(t *testing.T) {
+	sched := NewWFQScheduler().(*wfqScheduler)
+	sesh := &Session{streams: make(map[uint32]*Stream), scheduler: sched}
+	sb := &switchboard{sesh: sesh, scheduler: sched}
+
+	sb.dispatch(&Frame{Type: typePRIORITY, StreamID: 99, Payload: encodePriority(5, 0)})
+
+	if got := len(sched.queues); got != 0 {
+		t.Fatalf("expected no scheduler queue for an unknown stream, got %d", got)
+	}
+}
+
+// TestDispatchWNDUPDIgnoresUnknownStream guards against a WNDUPD frame for a
+// stream we've never seen a PSH for fabricating a phantom Stream: only PSH
+// may originate a stream, so an unknown-stream WNDUPD must be dropped like
+// FIN/RST already are instead of being treated the same as PSH.
+func TestDispatchWNDUPDIgnoresUnknownStream(t *testing.T) {
+	sched := NewWFQScheduler().(*wfqScheduler)
+	sesh := &Session{
+		streams:   make(map[uint32]*Stream),
+		scheduler: sched,
+		acceptCh:  make(chan *Stream, 1),
+	}
+	sb := &switchboard{sesh: sesh, scheduler: sched}
+
+	sb.dispatch(&Frame{Type: typeWNDUPD, StreamID: 99, Payload: encodeUint32(1000)})
+
+	if sesh.isStream(99) {
+		t.Fatalf("expected no stream to be created for an unknown-stream WNDUPD")
+	}
+	select {
+	case s := <-sesh.acceptCh:
+		t.Fatalf("expected no stream pushed to acceptCh, got %+v", s)
+	default:
+	}
+}